@@ -0,0 +1,193 @@
+// Package learn is a thin HTTP client for the Learn API: the service
+// `learn preview` and `learn watch` hand newly-uploaded curriculum content
+// off to so it can be built into a preview.
+package learn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultBaseURL is Learn's API host. Overridable via the "learn_api_url"
+// config key for staging or self-hosted Learn instances.
+const defaultBaseURL = "https://learn-2.galvanize.com/api/v1"
+
+// BuildResponse is Learn's response to a build request or a build-status poll.
+type BuildResponse struct {
+	ReleaseID  string `json:"release_id"`
+	PreviewURL string `json:"preview_url"`
+	Status     string `json:"status"`
+}
+
+// S3Credentials are temporary credentials Learn hands out so the CLI can
+// upload directly into Learn's own S3 bucket.
+type S3Credentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	BucketName      string `json:"bucket_name"`
+	KeyPrefix       string `json:"key_prefix"`
+}
+
+// Client is a thin HTTP client for the Learn API.
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// API is the package-level Learn client every command uses.
+var API = &Client{
+	BaseURL: baseURL(),
+	HTTP:    &http.Client{Timeout: 30 * time.Second},
+}
+
+func baseURL() string {
+	if url := viper.GetString("learn_api_url"); url != "" {
+		return url
+	}
+	return defaultBaseURL
+}
+
+func (c *Client) apiToken() string {
+	return viper.GetString("api_token")
+}
+
+// do sends a JSON request to path and decodes a JSON response into out (if
+// non-nil), returning an error for network failures or non-2xx responses.
+// ctx lets a caller cancel the request (and any retries a method layers on
+// top of it) when it's been superseded before Learn responds.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.apiToken())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %v", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		data, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("learn returned %d for %s: %s", res.StatusCode, path, data)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// RetrieveS3Credentials asks Learn for temporary credentials to its own S3
+// bucket, used by the default "aws" storage backend. This is a one-shot
+// lookup before any uploading starts, so unlike the build/poll calls below
+// it doesn't take a ctx a caller would need to cancel mid-flight.
+func (c *Client) RetrieveS3Credentials() (*S3Credentials, error) {
+	var creds S3Credentials
+	if err := c.do(context.Background(), http.MethodGet, "/s3_credentials", nil, &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+// BuildReleaseFromS3 tells Learn that new content is available at bucketKey
+// in its own S3 bucket and to build a preview release from it. ctx lets
+// watch mode cancel the request if a newer change supersedes this cycle
+// before Learn responds.
+func (c *Client) BuildReleaseFromS3(ctx context.Context, bucketKey string, isDirectory bool) (*BuildResponse, error) {
+	var res BuildResponse
+	body := map[string]interface{}{"bucket_key": bucketKey, "is_directory": isDirectory}
+	if err := c.do(ctx, http.MethodPost, "/releases", body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PollForBuildResponse polls Learn for releaseID's build status, waiting a
+// second between tries until it's done building or attempts runs out. ctx
+// lets watch mode cancel an in-flight poll -- including the wait between
+// tries -- the moment a newer change supersedes this cycle, instead of
+// running all the way to the attempt limit before the result is discarded.
+func (c *Client) PollForBuildResponse(ctx context.Context, releaseID string, attempts *uint8) (*BuildResponse, error) {
+	for {
+		var res BuildResponse
+		if err := c.do(ctx, http.MethodGet, "/releases/"+releaseID, nil, &res); err != nil {
+			return nil, err
+		}
+
+		if res.Status == "" || res.Status == "complete" {
+			return &res, nil
+		}
+
+		if *attempts == 0 {
+			return nil, fmt.Errorf("timed out waiting for release %s to finish building", releaseID)
+		}
+		*attempts--
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// BuildReleaseFromURL tells Learn that new content is available at url and
+// to build a preview release from it. Unlike BuildReleaseFromS3, url isn't a
+// key into Learn's own S3 bucket -- it's used for every other storage
+// backend (an S3-compatible endpoint, GCS, or a local directory served over
+// HTTP), none of which Learn can reach on its own, so the CLI hands it a
+// URL it can fetch the content from directly instead. ctx lets watch mode
+// cancel the request if a newer change supersedes this cycle before Learn
+// responds.
+func (c *Client) BuildReleaseFromURL(ctx context.Context, url string, isDirectory bool) (*BuildResponse, error) {
+	var res BuildResponse
+	body := map[string]interface{}{"content_url": url, "is_directory": isDirectory}
+	if err := c.do(ctx, http.MethodPost, "/releases", body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PreviewExists asks Learn whether content with this checksum has already
+// been uploaded and built, via a HEAD request, so previewCmd and watch mode
+// can skip a redundant upload and build cycle entirely.
+func (c *Client) PreviewExists(checksum string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, c.BaseURL+"/previews/"+checksum, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiToken())
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("request to check for an existing preview of %s failed: %v", checksum, err)
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode == http.StatusOK, nil
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// contentEntry is a single (path, mode, size, content-hash) tuple collected
+// while walking a source tree for contentChecksum.
+type contentEntry struct {
+	relPath string
+	mode    os.FileMode
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+// contentChecksum computes a deterministic digest of source's content that
+// depends only on the files that go into the archive, not on how they're
+// archived. Unlike hashing the zip bytes directly -- which shifts with
+// mtimes, walk order, or archive/zip's deflate output -- this borrows the
+// TarSum approach Docker used for build contexts: walk source once, sort
+// the entries by path, and feed path\0mode\0size\0sha256(content) for each
+// into a running sha256. Byte-identical content therefore always produces
+// the same digest, which is what makes server-side "already built"
+// short-circuiting (learn.API.PreviewExists) reliable. Both previewCmd and
+// watch mode call this before touching the zip at all, so a no-op rebuild
+// can be skipped without ever compressing or uploading anything.
+func contentChecksum(source string, matcher *ignoreMatcher) (string, error) {
+	var entries []contentEntry
+
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if relPath == "." {
+			if info.IsDir() {
+				return nil
+			}
+			// source is a single file: key its entry by the file's own name,
+			// as writeArchive does, so its content still reaches the digest
+			relPath = info.Name()
+		} else if matcher.Match(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		h := sha256.New()
+		if !info.IsDir() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+
+		entry := contentEntry{relPath: relPath, mode: info.Mode(), size: info.Size()}
+		copy(entry.sum[:], h.Sum(nil))
+		entries = append(entries, entry)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return digestEntries(entries), nil
+}
+
+// digestEntries sorts entries by path and feeds path\0mode\0size\0 plus
+// each entry's content hash into a running sha256, so byte-identical
+// content always produces the same digest regardless of walk order.
+func digestEntries(entries []contentEntry) string {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].relPath < entries[j].relPath })
+
+	digest := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintf(digest, "%s\x00%o\x00%d\x00", entry.relPath, entry.mode, entry.size)
+		digest.Write(entry.sum[:])
+	}
+
+	return hex.EncodeToString(digest.Sum(nil))
+}
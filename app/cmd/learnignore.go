@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// learnIgnoreFile is the name of the gitignore-style file, discovered at the
+// source root, that previewCmd uses to decide which files to leave out of
+// the uploaded archive.
+const learnIgnoreFile = ".learnignore"
+
+// defaultIgnorePatterns are always applied in addition to anything found in
+// .learnignore or passed via --exclude, so a preview never ships repo
+// internals or generated artifacts by accident.
+var defaultIgnorePatterns = []string{
+	".git/",
+	"node_modules/",
+	"*.swp",
+	".DS_Store",
+	autoConfigFileName,
+	tmpFile,
+}
+
+// ignorePattern is a single compiled line from a .learnignore file (or one
+// of defaultIgnorePatterns / --exclude).
+type ignorePattern struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	re       *regexp.Regexp
+}
+
+// matches reports whether relPath (slash-separated, relative to the source
+// root) is matched by this pattern. Non-anchored patterns (no "/" other than
+// a trailing one) are checked against every path segment so they behave like
+// gitignore and match at any depth.
+func (p ignorePattern) matches(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if p.anchored {
+		return p.re.MatchString(relPath)
+	}
+
+	segments := strings.Split(relPath, "/")
+	for i := range segments {
+		if p.re.MatchString(strings.Join(segments[i:], "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignoreMatcher holds the ordered set of patterns that apply to a preview,
+// combining the built-in defaults, an optional .learnignore file, and any
+// --exclude flags, in that order of application.
+type ignoreMatcher struct {
+	patterns []ignorePattern
+}
+
+// Match reports whether relPath should be excluded from the archive. As in
+// gitignore, the last pattern to match wins, so a later "!" line can
+// un-ignore something an earlier pattern excluded.
+func (m *ignoreMatcher) Match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.matches(relPath, isDir) {
+			ignored = !p.negate
+		}
+	}
+
+	return ignored
+}
+
+// loadIgnoreMatcher builds the ignoreMatcher for a preview. ignoreFilePath
+// overrides the default of "<source>/.learnignore" when non-empty; extraExcludes
+// are applied last so they can override anything else.
+func loadIgnoreMatcher(source, ignoreFilePath string, extraExcludes []string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+
+	for _, pattern := range defaultIgnorePatterns {
+		if err := m.addLine(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	path := ignoreFilePath
+	explicit := ignoreFilePath != ""
+	if path == "" {
+		path = filepath.Join(source, learnIgnoreFile)
+	}
+
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if err := m.addLine(scanner.Text()); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+	case os.IsNotExist(err) && !explicit:
+		// No .learnignore at the source root is fine; defaults still apply.
+	default:
+		return nil, fmt.Errorf("failed to read ignore file %s: %v", path, err)
+	}
+
+	for _, pattern := range extraExcludes {
+		if err := m.addLine(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *ignoreMatcher) addLine(line string) error {
+	pattern, err := parseIgnoreLine(line)
+	if err != nil {
+		return err
+	}
+	if pattern != nil {
+		m.patterns = append(m.patterns, *pattern)
+	}
+
+	return nil
+}
+
+// parseIgnoreLine parses a single gitignore-style line, returning nil for
+// blank lines and comments.
+func parseIgnoreLine(line string) (*ignorePattern, error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return nil, nil
+	}
+
+	negate := false
+	if strings.HasPrefix(trimmed, "!") {
+		negate = true
+		trimmed = trimmed[1:]
+	}
+
+	dirOnly := false
+	if strings.HasSuffix(trimmed, "/") {
+		dirOnly = true
+		trimmed = strings.TrimSuffix(trimmed, "/")
+	}
+
+	anchored := strings.Contains(trimmed, "/")
+	pattern := filepath.ToSlash(strings.TrimPrefix(trimmed, "/"))
+
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .learnignore pattern %q: %v", line, err)
+	}
+
+	return &ignorePattern{negate: negate, dirOnly: dirOnly, anchored: anchored, re: re}, nil
+}
+
+// compileGlob translates a gitignore-style glob (supporting "*", "?", and
+// the recursive "**") into an anchored regexp. It works segment-by-segment,
+// rather than translating the whole pattern as one string, so a "**"
+// segment can match zero directories as well as one or more -- e.g.
+// "**/node_modules" must match a top-level "node_modules", and
+// "foo/**/bar" must match "foo/bar", not just "foo/x/bar".
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	segments := strings.Split(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i, seg := range segments {
+		if i > 0 && segments[i-1] != "**" {
+			b.WriteString("/")
+		}
+
+		if seg == "**" {
+			if i == len(segments)-1 {
+				// Trailing "/**" matches everything under the preceding
+				// directory -- at least one more path segment, not zero.
+				b.WriteString(`.*`)
+			} else {
+				// Leading "**/" or a middle "/**/ " matches zero or more
+				// whole directories, so the separator it's standing in for
+				// is itself optional -- covering the zero-directories case.
+				b.WriteString(`(?:.*/)?`)
+			}
+			continue
+		}
+
+		quoted := regexp.QuoteMeta(seg)
+		quoted = strings.ReplaceAll(quoted, `\*`, `[^/]*`)
+		quoted = strings.ReplaceAll(quoted, `\?`, `[^/]`)
+		b.WriteString(quoted)
+	}
+
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
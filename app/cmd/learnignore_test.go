@@ -0,0 +1,75 @@
+package cmd
+
+import "testing"
+
+func TestCompileGlob(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		input   string
+		want    bool
+	}{
+		{"star matches within a segment", "*.swp", "file.swp", true},
+		{"star does not cross a separator", "*.swp", "dir/file.swp", false},
+		{"question mark matches one char", "a?c", "abc", true},
+		{"question mark does not match zero chars", "a?c", "ac", false},
+		{"leading globstar matches a top-level entry", "**/node_modules", "node_modules", true},
+		{"leading globstar matches nested entries", "**/node_modules", "a/b/node_modules", true},
+		{"leading globstar requires the literal suffix", "**/node_modules", "node_modules_extra", false},
+		{"middle globstar matches zero directories", "foo/**/bar", "foo/bar", true},
+		{"middle globstar matches one directory", "foo/**/bar", "foo/x/bar", true},
+		{"middle globstar matches several directories", "foo/**/bar", "foo/x/y/bar", true},
+		{"trailing globstar matches everything under the directory", "foo/**", "foo/x/y", true},
+		{"trailing globstar requires at least one more segment", "foo/**", "foo", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			re, err := compileGlob(c.pattern)
+			if err != nil {
+				t.Fatalf("compileGlob(%q): unexpected error: %v", c.pattern, err)
+			}
+
+			if got := re.MatchString(c.input); got != c.want {
+				t.Fatalf("compileGlob(%q).MatchString(%q) = %v, want %v", c.pattern, c.input, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreMatcherMatch(t *testing.T) {
+	m := &ignoreMatcher{}
+	for _, line := range []string{
+		"node_modules/",
+		"*.swp",
+		"dist/**/*.map",
+		"!dist/keep/build.map",
+	} {
+		if err := m.addLine(line); err != nil {
+			t.Fatalf("addLine(%q): unexpected error: %v", line, err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		relPath string
+		isDir   bool
+		want    bool
+	}{
+		{"top-level node_modules directory", "node_modules", true, true},
+		{"nested node_modules directory", "src/node_modules", true, true},
+		{"node_modules is not ignored as a file", "node_modules", false, false},
+		{"swap file at any depth", "units/lesson-1.md.swp", false, true},
+		{"nested map file under dist", "dist/a/b/bundle.map", false, true},
+		{"negated map file wins as the last match", "dist/keep/build.map", false, false},
+		{"unrelated file is kept", "units/lesson-1.md", false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := m.Match(c.relPath, c.isDir); got != c.want {
+				t.Fatalf("Match(%q, %v) = %v, want %v", c.relPath, c.isDir, got, c.want)
+			}
+		})
+	}
+}
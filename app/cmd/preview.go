@@ -2,9 +2,8 @@ package cmd
 
 import (
 	"archive/zip"
+	"context"
 	"crypto/md5"
-	"crypto/sha256"
-	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -17,29 +16,56 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/briandowns/spinner"
 	pb "github.com/cheggaaa/pb/v3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/gSchool/glearn-cli/api/learn"
+	"github.com/gSchool/glearn-cli/app/filebackend"
 	proxyReader "github.com/gSchool/glearn-cli/app/proxy_reader"
+	"github.com/gSchool/glearn-cli/internal/safezip"
 )
 
 // tmpFile is used throughout as the temporary zip file target location.
 const tmpFile string = "preview-curriculum.zip"
 
+// signedURLTTL is how long a SignedURL notifyLearn hands to Learn for a
+// non-"aws" backend stays valid -- long enough for Learn to fetch and build
+// the content, not so long that a stale URL lingers as a standing credential.
+const signedURLTTL = time.Hour
+
+// autoConfigFileName is the generated config file written by createAutoConfig
+// when a block doesn't already have one.
+const autoConfigFileName string = "autoconfig.yaml"
+
+// ignoreFilePath and excludePatterns back the --ignore-file and --exclude
+// flags, letting users override or extend the .learnignore rules per invocation.
+var ignoreFilePath string
+var excludePatterns []string
+
+// resumeUpload, uploadPartSize, and uploadConcurrency back the --resume,
+// --part-size, and --concurrency flags for multipart uploads.
+var resumeUpload bool
+var uploadPartSize int64
+var uploadConcurrency int
+
+func init() {
+	previewCmd.Flags().StringVar(&ignoreFilePath, "ignore-file", "", "path to a gitignore-style file to use instead of <source>/.learnignore")
+	previewCmd.Flags().StringArrayVar(&excludePatterns, "exclude", nil, "additional gitignore-style pattern to exclude from the preview archive (repeatable)")
+	previewCmd.Flags().BoolVar(&resumeUpload, "resume", false, "persist multipart upload state under ~/.cache/glearn/uploads; the streamed archive can't be replayed, so an interrupted upload is aborted and started over on the next invocation")
+	previewCmd.Flags().Int64Var(&uploadPartSize, "part-size", 5*1024*1024, "multipart upload part size, in bytes")
+	previewCmd.Flags().IntVar(&uploadConcurrency, "concurrency", 0, "number of parts to upload concurrently (0 uses the SDK default)")
+}
+
 // previewCmd is executed when the `learn preview` command is used. Preview's concerns:
-// 1. Compress directory/file into target location.
-// 2. Defer cleaning up the file after command is finished.
-// 3. Create a checksum for the zip file.
-// 4. Upload the zip file to s3.
-// 5. Notify learn that new content is available for building.
-// 6. Handle progress bar for s3 upload.
+//  1. Compute a content-addressable checksum of the source directory/file,
+//     independent of zip framing.
+//  2. Skip compressing and uploading entirely if Learn already has that checksum.
+//  3. Otherwise stream a freshly-compressed archive straight into the
+//     configured storage backend, without spooling it to disk first.
+//  4. Notify learn that new content is available for building.
+//  5. Handle progress bar for the upload.
 var previewCmd = &cobra.Command{
 	Use:   "preview [file_path]",
 	Short: "Uploads content and builds a preview.",
@@ -63,38 +89,59 @@ var previewCmd = &cobra.Command{
 		// Detect config file
 		doesConfigExistOrCreate(args[0], UnitsDirectory)
 
-		// Compress directory, output -> tmpFile
-		err := compressDirectory(args[0], tmpFile)
+		// Build the set of .learnignore rules (built-in defaults + file + --exclude)
+		// so we don't zip up .git, node_modules, or anything else the user excluded
+		matcher, err := loadIgnoreMatcher(args[0], ignoreFilePath, excludePatterns)
 		if err != nil {
-			previewCmdError(fmt.Sprintf("Error compressing directory %s: %v", args[0], err))
+			previewCmdError(fmt.Sprintf("Failed to load .learnignore rules: %v", err))
 			return
 		}
 
-		// Removes artifacts on user's machine
-		defer cleanUpFiles()
-
-		// Open file so we can get a checksum as well as send to s3
-		f, err := os.Open(tmpFile)
+		// Build the configured storage backend (AWS S3 by default) before
+		// spending any time compressing, so a misconfigured backend fails fast
+		storageCfg := loadStorageConfig()
+		backend, err := filebackend.New(storageCfg)
 		if err != nil {
-			previewCmdError(fmt.Sprintf("Failed to open file %q, %v", tmpFile, err))
+			previewCmdError(fmt.Sprintf("Failed to configure storage backend: %v", err))
 			return
 		}
-		defer f.Close()
 
-		// Create checksum of files in directory
-		checksum, err := createChecksumFromZip(f)
+		if err := backend.TestConnection(); err != nil {
+			previewCmdError(fmt.Sprintf("Storage backend is not reachable: %v", err))
+			return
+		}
+
+		// Compute the content checksum before touching the zip at all -- an
+		// unchanged checksum (Learn already has this content) means we can
+		// skip compressing as well as uploading
+		checksum, err := contentChecksum(args[0], matcher)
 		if err != nil {
-			previewCmdError(fmt.Sprintf("Failed to create checksum for compressed file. Err: %v", err))
+			previewCmdError(fmt.Sprintf("Failed to checksum %s: %v", args[0], err))
 			return
 		}
 
-		// Send compressed zip file to s3
-		bucketKey, err := uploadToS3(f, checksum)
+		key := fmt.Sprintf("%s-%s", checksum, tmpFile)
+		bucketKey := backend.Key(key)
+
+		// Ask Learn whether this exact content has already been uploaded so we can
+		// skip uploading it all over again
+		exists, err := learn.API.PreviewExists(checksum)
 		if err != nil {
-			previewCmdError(fmt.Sprintf("Failed to upload zip file to s3. Err: %v", err))
+			previewCmdError(fmt.Sprintf("Failed to check Learn for an existing preview. Err: %v", err))
 			return
 		}
 
+		if exists {
+			fmt.Println("Content matches a previous preview; skipping upload...")
+		} else {
+			archive := compressDirectory(args[0], matcher)
+
+			if _, err := uploadArchive(context.Background(), backend, archive, key); err != nil {
+				previewCmdError(fmt.Sprintf("Failed to upload zip file. Err: %v", err))
+				return
+			}
+		}
+
 		// Get os.FileInfo from call to os.Stat so we can see if it is a single file or directory
 		fileInfo, err := os.Stat(args[0])
 		if err != nil {
@@ -110,8 +157,8 @@ var previewCmd = &cobra.Command{
 		s.Color("green")
 		s.Start()
 
-		// Let Learn know there is new preview content on s3, where it is, and to build it
-		res, err := learn.API.BuildReleaseFromS3(bucketKey, isDirectory)
+		// Let Learn know there is new preview content and where to fetch it
+		res, err := notifyLearn(context.Background(), storageCfg, backend, key, bucketKey, isDirectory)
 		if err != nil {
 			previewCmdError(fmt.Sprintf("Failed to notify learn of new preview content. Err: %v", err))
 			return
@@ -122,7 +169,7 @@ var previewCmd = &cobra.Command{
 		// poll for them because the call to BuildReleaseFromS3 will get a preview_url right away
 		if isDirectory {
 			var attempts uint8 = 20
-			res, err = learn.API.PollForBuildResponse(res.ReleaseID, &attempts)
+			res, err = learn.API.PollForBuildResponse(context.Background(), res.ReleaseID, &attempts)
 			if err != nil {
 				previewCmdError(fmt.Sprintf("Failed to poll Learn for your new preview build. Err: %v", err))
 				return
@@ -147,59 +194,76 @@ func previewCmdError(msg string) {
 	os.Exit(1)
 }
 
-// uploadToS3 takes a file and it's checksum and uploads it to s3 in the appropriate bucket/key
-func uploadToS3(file *os.File, checksum string) (string, error) {
-	// Retrieve the application credentials from AWS
-	creds, err := learn.API.RetrieveS3Credentials()
-	if err != nil {
-		return "", fmt.Errorf(
-			"Could not retrieve credentials from Learn. Please ensure you have the right API key in your ~/.glearn-config.yaml %s",
-			file.Name(),
-		)
+// loadStorageConfig reads the "storage" section of ~/.glearn-config.yaml
+// (via viper) into a filebackend.Config. Leaving storage.backend unset
+// keeps the existing AWS-backed behavior, pulling credentials from Learn.
+func loadStorageConfig() filebackend.Config {
+	return filebackend.Config{
+		Backend:         viper.GetString("storage.backend"),
+		Endpoint:        viper.GetString("storage.endpoint"),
+		Region:          viper.GetString("storage.region"),
+		Bucket:          viper.GetString("storage.bucket"),
+		KeyPrefix:       viper.GetString("storage.key_prefix"),
+		PathStyle:       viper.GetBool("storage.path_style"),
+		AccessKeyID:     viper.GetString("storage.access_key_id"),
+		SecretAccessKey: viper.GetString("storage.secret_access_key"),
+		LocalDir:        viper.GetString("storage.local_dir"),
+		LocalBaseURL:    viper.GetString("storage.local_base_url"),
+		PartSize:        uploadPartSize,
+		Concurrency:     uploadConcurrency,
+		Resume:          resumeUpload,
 	}
+}
 
-	// Set up an AWS session with the user's credentials
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("us-west-2"),
-		Credentials: credentials.NewStaticCredentials(
-			creds.AccessKeyID,
-			creds.SecretAccessKey,
-			"",
-		),
-	})
-
-	// Create new uploader and specify buffer size (in bytes) to use when buffering
-	// data into chunks and sending them as parts to S3 and clean up on error
-	uploader := s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
-		u.PartSize = 5 * 1024 * 1024 // 5,242,880 bytes or 5.24288 Mb which is the default minimum here
-		u.LeavePartsOnError = false  // If an error occurs during upload to s3, clean up & don't leave partial upload there
-	})
-
-	// Generate the bucket key using the key prefix, checksum, and tmpFile name
-	bucketKey := fmt.Sprintf("%s/%s-%s", creds.KeyPrefix, checksum, tmpFile)
-
-	// Obtain FileInfo so we can look at length in bytes
-	fileStats, err := file.Stat()
-	if err != nil {
-		return "", fmt.Errorf("Could not obtain file stats for %s", file.Name())
+// notifyLearn tells Learn that new content is ready to build. The "aws"
+// backend (the default) uploads directly into Learn's own S3 bucket, so
+// Learn can fetch it by bucket key alone. Every other backend -- an
+// S3-compatible endpoint, GCS, or a local directory served over HTTP --
+// isn't reachable by Learn through that bucket key, so instead it's handed
+// a signed, time-limited URL to fetch the content from directly.
+func notifyLearn(ctx context.Context, cfg filebackend.Config, backend filebackend.Backend, key, bucketKey string, isDirectory bool) (*learn.BuildResponse, error) {
+	switch cfg.Backend {
+	case "", "aws":
+		return learn.API.BuildReleaseFromS3(ctx, bucketKey, isDirectory)
+	default:
+		url, err := backend.SignedURL(key, signedURLTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate a signed URL for Learn to fetch the upload: %v", err)
+		}
+		return learn.API.BuildReleaseFromURL(ctx, url, isDirectory)
 	}
+}
 
-	// Create and start a new progress bar with a fixed width
-	bar := pb.Full.Start64(fileStats.Size()).SetWidth(100)
-
-	// Create a ProxyReader and attach the file and progress bar
-	pr := proxyReader.New(file, bar)
+// uploadArchive streams r to the configured storage backend under key,
+// rendering a progress bar as it streams, and returns the key the backend
+// actually stored it under. Since a streamed archive's total size isn't
+// known up front, the bar tracks bytes transferred rather than percent complete.
+// ctx is threaded through to the backend so watch mode can cancel an
+// in-flight upload a newer change has already superseded.
+func uploadArchive(ctx context.Context, backend filebackend.Backend, r io.Reader, key string) (string, error) {
+	// Create and start a progress bar that just tracks bytes transferred
+	bar := pb.New64(0)
+	bar.Set(pb.Bytes, true)
+	bar.Start()
+
+	// Create a ProxyReader and attach the archive and progress bar. r is a
+	// streamed archive and isn't seekable, but preserve Seek on the wrapped
+	// reader when it happens to be (e.g. in tests) since a resumable upload
+	// needs to rewind and re-verify already-uploaded parts before trusting them.
+	var pr io.Reader = proxyReader.New(r, bar)
+	if seeker, ok := r.(io.Seeker); ok {
+		pr = struct {
+			io.Reader
+			io.Seeker
+		}{pr, seeker}
+	}
 
 	fmt.Println("Uploading assets to Learn...")
 
-	// Upload compressed zip file to s3
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(creds.BucketName),
-		Key:    aws.String(bucketKey),
-		Body:   pr, // As our file is read and uploaded, our proxy reader will update/render the progress bar
-	})
+	// As the archive is read and uploaded, our proxy reader will update/render the progress bar
+	bucketKey, err := backend.Upload(ctx, key, pr, 0)
 	if err != nil {
-		return "", fmt.Errorf("Error uploading assets to s3: %v", err)
+		return "", err
 	}
 
 	bar.Finish()
@@ -207,63 +271,80 @@ func uploadToS3(file *os.File, checksum string) (string, error) {
 	return bucketKey, nil
 }
 
-// createChecksumFromZip takes a pointer to a file and creates a sha256 checksum
-// of the content. We use this for naming the s3 bucket key so that we don't write
-// duplicates to s3. The call to io.Copy actually consumes the read position of
-// the file to EOF so we call file.Seek and set the read position back to the
-// beginning of the file
-func createChecksumFromZip(file *os.File) (string, error) {
-	// Create a sha256 hash of the curriculum directory
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
-	}
-
-	// Make the hash URL safe with base64
-	checksum := base64.URLEncoding.EncodeToString(hash.Sum(nil))
-
-	// The io.Copy call for producing the hash consumed the read position of the
-	// file (file now at EOF). Need to reset to beginning for sending to s3
-	_, err := file.Seek(0, io.SeekStart)
-	if err != nil {
-		log.Fatal(err)
-		os.Exit(1)
-		return "", err
-	}
-
-	return checksum, nil
-}
-
-// cleanUpFiles removes the tmp zipfile that was created for uploading to s3. We
-// wouldn't want to leave artifacts on user's machines
+// cleanUpFiles removes the tmp zipfile a previous version of this command
+// used to write to disk -- a best-effort cleanup of artifacts a crashed run
+// of that version may have left behind. It's not an error if there's
+// nothing to remove.
 func cleanUpFiles() {
 	err := os.Remove(tmpFile)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		fmt.Println("Sorry, we had trouble cleaning up the zip file created for curriculum preview")
 	}
 }
 
-// compressDirectory takes a source file path (where the content you want zipped lives)
-// and a target file path (where to put the zip file) and recursively compresses the source.
-// Source can either be a directory or a single file
-func compressDirectory(source, target string) error {
-	// Create file with target name and defer its closing
-	zipfile, err := os.Create(target)
-	if err != nil {
-		return err
-	}
-	defer zipfile.Close()
+// compressDirectory takes a source file path (where the content you want zipped
+// lives) and an ignoreMatcher, and returns an io.Reader that produces the zip
+// archive of source on the fly. This lets callers stream the archive straight
+// into an upload instead of buffering the whole thing on disk first, which
+// matters for large curricula, read-only filesystems, and CI runners with a
+// small tmpfs. Source can either be a directory or a single file
+func compressDirectory(source string, matcher *ignoreMatcher) io.Reader {
+	pr, pw := io.Pipe()
 
-	// Create a new zip writer and pass our zipfile in
-	archive := zip.NewWriter(zipfile)
+	go func() {
+		pw.CloseWithError(writeArchive(pw, source, matcher))
+	}()
+
+	return pr
+}
+
+// writeArchive does the actual work of walking source and writing a zip
+// archive of it to w, skipping anything matcher excludes.
+func writeArchive(w io.Writer, source string, matcher *ignoreMatcher) error {
+	// Create a new zip writer and pass our destination writer in. zip.Writer
+	// tracks offsets itself, so w doesn't need to be seekable
+	archive := zip.NewWriter(w)
 	defer archive.Close()
 
+	return walkSource(source, matcher, func(path string, info os.FileInfo, header *zip.FileHeader) error {
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(writer, file)
+
+		return err
+	})
+}
+
+// walkSource walks source once, skipping anything matcher excludes and
+// refusing to follow a symlink that escapes source, and calls visit with a
+// sanitized zip.FileHeader for every entry that survives.
+func walkSource(source string, matcher *ignoreMatcher, visit func(path string, info os.FileInfo, header *zip.FileHeader) error) error {
 	// Get os.FileInfo about our source
 	info, err := os.Stat(source)
 	if err != nil {
 		return nil
 	}
 
+	// Resolve the source root through any symlinks so we can detect (and refuse)
+	// entries that would escape it via a symlinked file or directory
+	resolvedSource, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		return err
+	}
+
 	// Check to see if the provided source file is a directory and set baseDir if so
 	var baseDir string
 	if info.IsDir() {
@@ -271,10 +352,39 @@ func compressDirectory(source, target string) error {
 	}
 
 	// Walk the whole filepath
-	filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Figure out the path relative to source so it can be checked against
+		// the ignoreMatcher, skipping whole directories so we don't pay the
+		// cost of descending into things like node_modules
+		relPath, err := filepath.Rel(source, path)
 		if err != nil {
 			return err
 		}
+		relPath = filepath.ToSlash(relPath)
+
+		if relPath != "." && matcher.Match(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Refuse to follow a symlink that resolves outside of the source root --
+		// otherwise a malicious or careless symlink could smuggle arbitrary
+		// filesystem paths into the archive
+		if info.Mode()&os.ModeSymlink != 0 {
+			ok, err := safezip.ResolvesUnder(resolvedSource, path)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("refusing to include symlink %q: it resolves outside of %q", path, source)
+			}
+		}
 
 		// Creates a partially-populated FileHeader from an os.FileInfo
 		header, err := zip.FileInfoHeader(info)
@@ -288,6 +398,14 @@ func compressDirectory(source, target string) error {
 			header.Name = filepath.Join(baseDir, strings.TrimPrefix(path, source))
 		}
 
+		// Canonicalize and validate the header name so a crafted path or a
+		// non-Unix separator can't produce a "zip slip" entry
+		sanitizedName, err := safezip.SanitizeName(header.Name)
+		if err != nil {
+			return err
+		}
+		header.Name = sanitizedName
+
 		// Check if the file we are iterating is a directory and update the header.Name
 		// or the header.Method appropriately
 		if info.IsDir() {
@@ -296,30 +414,8 @@ func compressDirectory(source, target string) error {
 			header.Method = zip.Deflate
 		}
 
-		//  Add a file to the zip archive using the provided FileHeader for the file metadata
-		writer, err := archive.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		// Return nil if at this point if info is a directory
-		if info.IsDir() {
-			return nil
-		}
-
-		// If it was not a directory, we open the file and copy it into the archive writer
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-
-		_, err = io.Copy(writer, file)
-
-		return err
+		return visit(path, info, header)
 	})
-
-	return err
 }
 
 // Check whether or nor a config file exists and if it does not we are going to attempt to create one
@@ -369,7 +465,7 @@ func createAutoConfig(target, requestedUnitsDir string) {
 	}
 
 	// The config file location that we will be creating
-	autoConfigYamlPath := blockRoot + "autoconfig.yaml"
+	autoConfigYamlPath := blockRoot + autoConfigFileName
 
 	// Remove the existing one if its around
 	_, autoYamlExists := os.Stat(autoConfigYamlPath)
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/gSchool/glearn-cli/api/learn"
+	"github.com/gSchool/glearn-cli/app/filebackend"
+)
+
+// watchDebounce and watchOpen back the --debounce and --open flags.
+var watchDebounce time.Duration
+var watchOpen bool
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 750*time.Millisecond, "how long to wait after the last file change before re-previewing")
+	watchCmd.Flags().BoolVar(&watchOpen, "open", false, "open the preview URL every successful cycle, not just the first")
+}
+
+// watchCmd is executed when the `learn watch` command is used. It runs the
+// same compress/checksum/upload/notify pipeline as previewCmd, but keeps
+// watching the source tree afterwards and re-runs the pipeline on every
+// change, debouncing bursts of edits and skipping no-op rebuilds whose
+// content checksum hasn't changed.
+var watchCmd = &cobra.Command{
+	Use:   "watch [file_path]",
+	Short: "Watches a directory and rebuilds its preview on every change.",
+	Long: `
+		The watch command takes a path to a directory, uploads it the same way
+		"learn preview" does, and then keeps watching the tree: every time a file
+		changes it debounces the burst of events and re-runs the preview pipeline,
+		skipping the upload entirely when the content checksum hasn't changed.
+	`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if viper.Get("api_token") == "" || viper.Get("api_token") == nil {
+			previewCmdError("Please set your API token first with `learn set --api_token=value`")
+		}
+
+		source := args[0]
+		doesConfigExistOrCreate(source, UnitsDirectory)
+
+		matcher, err := loadIgnoreMatcher(source, ignoreFilePath, excludePatterns)
+		if err != nil {
+			previewCmdError(fmt.Sprintf("Failed to load .learnignore rules: %v", err))
+			return
+		}
+
+		storageCfg := loadStorageConfig()
+		backend, err := filebackend.New(storageCfg)
+		if err != nil {
+			previewCmdError(fmt.Sprintf("Failed to configure storage backend: %v", err))
+			return
+		}
+
+		if err := backend.TestConnection(); err != nil {
+			previewCmdError(fmt.Sprintf("Storage backend is not reachable: %v", err))
+			return
+		}
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			previewCmdError(fmt.Sprintf("Failed to start file watcher: %v", err))
+			return
+		}
+		defer watcher.Close()
+
+		if err := addWatchDirs(watcher, source, matcher); err != nil {
+			previewCmdError(fmt.Sprintf("Failed to watch %s: %v", source, err))
+			return
+		}
+
+		w := &watchRunner{source: source, matcher: matcher, backend: backend, storageCfg: storageCfg}
+
+		fmt.Printf("Watching %s for changes (debounce %s). Ctrl-C to stop.\n", source, watchDebounce)
+
+		// Build and open the first preview immediately, then react to changes.
+		w.runCycle()
+
+		var debounceTimer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				if debounceTimer == nil {
+					debounceTimer = time.AfterFunc(watchDebounce, w.runCycle)
+				} else {
+					debounceTimer.Reset(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Printf("watch: %v\n", err)
+			}
+		}
+	},
+}
+
+// addWatchDirs adds source, and every non-ignored subdirectory beneath it, to
+// watcher, since fsnotify only watches the directories it's explicitly
+// handed and isn't recursive on its own.
+func addWatchDirs(watcher *fsnotify.Watcher, source string, matcher *ignoreMatcher) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if relPath != "." && matcher.Match(relPath, true) {
+			return filepath.SkipDir
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+// watchRunner holds the state shared across watch cycles: the checksum last
+// uploaded (so no-op rebuilds are skipped), a generation counter used to
+// discard a cycle's result if a newer change supersedes it, and the cancel
+// func for whichever cycle is currently uploading or polling Learn, so a
+// newer change can actually stop that work instead of just outrunning it.
+type watchRunner struct {
+	source     string
+	matcher    *ignoreMatcher
+	backend    filebackend.Backend
+	storageCfg filebackend.Config
+
+	mu            sync.Mutex
+	generation    int
+	lastChecksum  string
+	openedBrowser bool
+	cancel        context.CancelFunc
+}
+
+// runCycle compresses, checksums, and (if the content changed) uploads and
+// notifies Learn, printing a single status line rather than reopening the
+// browser on every pass. If a newer change starts a cycle of its own while
+// this one is still uploading or polling, that newer cycle cancels this
+// one's context instead of letting both run to completion.
+func (w *watchRunner) runCycle() {
+	w.mu.Lock()
+	w.generation++
+	gen := w.generation
+	if w.cancel != nil {
+		w.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.mu.Unlock()
+	defer cancel()
+
+	// Compute the content checksum before touching the zip at all -- an
+	// unchanged checksum means we can skip compressing as well as uploading
+	checksum, err := contentChecksum(w.source, w.matcher)
+	if err != nil {
+		fmt.Printf("watch: failed to checksum %s: %v\n", w.source, err)
+		return
+	}
+
+	w.mu.Lock()
+	unchanged := checksum == w.lastChecksum
+	w.mu.Unlock()
+	if unchanged {
+		fmt.Println("watch: no content changes, skipping rebuild")
+		return
+	}
+
+	key := fmt.Sprintf("%s-%s", checksum, tmpFile)
+	bucketKey := w.backend.Key(key)
+
+	exists, err := learn.API.PreviewExists(checksum)
+	if err != nil {
+		fmt.Printf("watch: failed to check Learn for an existing preview: %v\n", err)
+		return
+	}
+
+	if !exists {
+		archive := compressDirectory(w.source, w.matcher)
+
+		if _, err := uploadArchive(ctx, w.backend, archive, key); err != nil {
+			if ctx.Err() != nil {
+				fmt.Println("watch: superseded by a newer change, canceling upload")
+				return
+			}
+			fmt.Printf("watch: failed to upload: %v\n", err)
+			return
+		}
+	}
+
+	fileInfo, err := os.Stat(w.source)
+	if err != nil {
+		fmt.Printf("watch: failed to stat %s: %v\n", w.source, err)
+		return
+	}
+
+	res, err := notifyLearn(ctx, w.storageCfg, w.backend, key, bucketKey, fileInfo.IsDir())
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Println("watch: superseded by a newer change, canceling upload")
+			return
+		}
+		fmt.Printf("watch: failed to notify Learn: %v\n", err)
+		return
+	}
+
+	if fileInfo.IsDir() {
+		var attempts uint8 = 20
+		res, err = learn.API.PollForBuildResponse(ctx, res.ReleaseID, &attempts)
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Println("watch: superseded by a newer change, canceling in-flight poll")
+				return
+			}
+			fmt.Printf("watch: failed polling for build response: %v\n", err)
+			return
+		}
+	}
+
+	// If a newer change arrived while we were uploading/polling, that cycle
+	// already has (or will have) a fresher result -- let it win instead of
+	// reporting a stale URL or recording this checksum as the latest.
+	w.mu.Lock()
+	if gen != w.generation {
+		w.mu.Unlock()
+		fmt.Println("watch: superseded by a newer change, discarding this build")
+		return
+	}
+	w.lastChecksum = checksum
+	shouldOpen := watchOpen || !w.openedBrowser
+	w.openedBrowser = true
+	w.mu.Unlock()
+
+	fmt.Printf("watch: preview ready at %s\n", res.PreviewURL)
+
+	if shouldOpen {
+		exec.Command("bash", "-c", fmt.Sprintf("open %s", res.PreviewURL)).Output()
+	}
+}
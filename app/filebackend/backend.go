@@ -0,0 +1,110 @@
+// Package filebackend abstracts the storage destination a preview's
+// compressed archive is uploaded to, so previewCmd (and any future
+// publish/build commands) depend on this interface rather than being
+// hard-wired to AWS S3.
+package filebackend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Backend is implemented by every storage destination previewCmd can
+// upload to: AWS S3, an S3-compatible endpoint, Google Cloud Storage, or a
+// local directory served over HTTP.
+type Backend interface {
+	// Key returns the full key the backend would store name under (applying
+	// any configured prefix), without uploading anything. Callers use this to
+	// predict an upload's destination ahead of time, e.g. to ask Learn whether
+	// that content already exists before compressing or uploading it.
+	Key(name string) string
+
+	// Upload streams r to key and returns the key the backend actually
+	// stored it under. size is r's length in bytes, or 0 when it isn't
+	// known up front, as for a streamed archive.
+	Upload(ctx context.Context, key string, r io.Reader, size int64) (string, error)
+
+	// TestConnection verifies the backend is reachable and correctly
+	// configured, without uploading anything.
+	TestConnection() error
+
+	// SignedURL returns a URL the object previously uploaded at key can be
+	// fetched from for ttl.
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// Config holds the settings read from the "storage" section of
+// ~/.glearn-config.yaml that select and configure a Backend.
+type Config struct {
+	// Backend is one of "aws" (default), "s3" (S3-compatible endpoint),
+	// "gcs", or "local".
+	Backend string
+
+	// Endpoint is the S3-compatible endpoint URL (MinIO, Wasabi, Spaces, ...).
+	// Used by "s3", and by "aws" as well when set.
+	Endpoint string
+
+	// Region is the bucket's region. Used by "aws" and "s3".
+	Region string
+
+	// Bucket is the destination bucket name. Required for "s3" and "gcs".
+	// "aws" falls back to the bucket learn.API.RetrieveS3Credentials returns
+	// when unset.
+	Bucket string
+
+	// KeyPrefix is prepended to every upload key by "aws" and "s3"; "gcs"
+	// and "local" don't apply it. "aws" falls back to the prefix
+	// learn.API.RetrieveS3Credentials returns when unset.
+	KeyPrefix string
+
+	// PathStyle forces path-style bucket addressing instead of
+	// virtual-hosted style, which most S3-compatible services require.
+	PathStyle bool
+
+	// AccessKeyID / SecretAccessKey are static credentials for "s3". "aws"
+	// uses them only when Bucket is set; otherwise it uses
+	// learn.API.RetrieveS3Credentials() instead.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// LocalDir is the directory the "local" backend writes into.
+	LocalDir string
+
+	// LocalBaseURL is the base URL LocalDir is served from, used to build
+	// SignedURL results for the "local" backend.
+	LocalBaseURL string
+
+	// PartSize is the size, in bytes, of each part in a multipart upload.
+	// Only used by "aws" and "s3". Zero keeps the SDK's default (5 MiB).
+	PartSize int64
+
+	// Concurrency is the number of parts uploaded in parallel. Only used by
+	// "aws" and "s3". Zero keeps the SDK's default.
+	Concurrency int
+
+	// Resume makes "aws" and "s3" persist multipart upload progress to
+	// ~/.cache/glearn/uploads so an interrupted upload can pick back up on
+	// the next invocation instead of starting over. Already-uploaded parts
+	// are reused only when the reader passed to Upload is seekable and
+	// replays the same bytes; otherwise the upload starts over.
+	Resume bool
+}
+
+// New constructs the Backend selected by cfg.Backend, defaulting to "aws"
+// when unset so existing configs keep working unchanged.
+func New(cfg Config) (Backend, error) {
+	switch cfg.Backend {
+	case "", "aws":
+		return newS3Backend(cfg, true)
+	case "s3":
+		return newS3Backend(cfg, false)
+	case "gcs":
+		return newGCSBackend(cfg)
+	case "local":
+		return newLocalBackend(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage.backend %q: expected aws, s3, gcs, or local", cfg.Backend)
+	}
+}
@@ -0,0 +1,86 @@
+package filebackend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localBackend implements Backend by writing into a directory expected to
+// be served over HTTP, for offline/self-hosted Learn deployments.
+type localBackend struct {
+	dir     string
+	baseURL string
+}
+
+func newLocalBackend(cfg Config) (*localBackend, error) {
+	if cfg.LocalDir == "" {
+		return nil, fmt.Errorf("storage.local_dir is required when storage.backend is \"local\"")
+	}
+
+	if err := os.MkdirAll(cfg.LocalDir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create storage.local_dir %q: %v", cfg.LocalDir, err)
+	}
+
+	return &localBackend{
+		dir:     cfg.LocalDir,
+		baseURL: strings.TrimSuffix(cfg.LocalBaseURL, "/"),
+	}, nil
+}
+
+// Key returns name unchanged: the local backend doesn't prefix keys.
+func (b *localBackend) Key(name string) string {
+	return name
+}
+
+func (b *localBackend) Upload(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	target := filepath.Join(b.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return "", fmt.Errorf("error writing %q: %v", target, err)
+	}
+
+	return key, nil
+}
+
+func (b *localBackend) TestConnection() error {
+	info, err := os.Stat(b.dir)
+	if err != nil {
+		return fmt.Errorf("storage.local_dir %q is not reachable: %v", b.dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("storage.local_dir %q is not a directory", b.dir)
+	}
+
+	return nil
+}
+
+func (b *localBackend) SignedURL(key string, ttl time.Duration) (string, error) {
+	if b.baseURL == "" {
+		return "", fmt.Errorf("storage.local_base_url must be set to generate preview URLs for the local backend")
+	}
+
+	// Escape each path segment individually rather than the whole key with
+	// url.PathEscape, which would turn any "/" in key (a nested path) into
+	// a literal "%2F" instead of a working nested path.
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return fmt.Sprintf("%s/%s", b.baseURL, strings.Join(segments, "/")), nil
+}
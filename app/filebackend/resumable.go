@@ -0,0 +1,280 @@
+package filebackend
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// partRecord is one already-uploaded part of a resumable upload. SHA256 is
+// the hash of that part's own bytes, kept so a resumed upload can prove the
+// bytes it's about to skip over are actually the ones S3 already has,
+// rather than just assuming a replay reproduces them.
+type partRecord struct {
+	PartNumber int64   `json:"part_number"`
+	Size       int64   `json:"size"`
+	SHA256     string  `json:"sha256"`
+	ETag       *string `json:"etag"`
+}
+
+// resumeState is the on-disk record of an in-progress multipart upload,
+// persisted so an interrupted upload of a large curriculum can resume on the
+// next invocation instead of starting over from byte zero.
+type resumeState struct {
+	Bucket   string        `json:"bucket"`
+	Key      string        `json:"key"`
+	UploadID string        `json:"upload_id"`
+	Parts    []*partRecord `json:"parts"`
+}
+
+// resumeStatePath returns the cache file a resumable upload of cacheKey
+// persists its state to. cacheKey is expected to already be filesystem-safe
+// (s3Backend passes the unprefixed upload key, which for previewCmd is the
+// content checksum plus a fixed file name and never contains a separator).
+func resumeStatePath(cacheKey string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(home, ".cache", "glearn", "uploads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, cacheKey+".json"), nil
+}
+
+func loadResumeState(cacheKey string) (*resumeState, error) {
+	path, err := resumeStatePath(cacheKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state resumeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func saveResumeState(cacheKey string, state *resumeState) error {
+	path, err := resumeStatePath(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func clearResumeState(cacheKey string) {
+	path, err := resumeStatePath(cacheKey)
+	if err != nil {
+		return
+	}
+
+	os.Remove(path)
+}
+
+// abandonUpload best-effort aborts a stale multipart upload on S3. Called
+// when a resumed upload's state can't be trusted, so the orphaned upload
+// doesn't linger (and keep billing for storage) with no way it'll ever be
+// completed.
+func abandonUpload(ctx context.Context, client *s3.S3, bucket, key, uploadID string) {
+	client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+}
+
+// verifyReplayedParts seeks r back to the start and re-hashes exactly the
+// bytes each of parts claims to cover, confirming they match before trusting
+// a skip-ahead resume. It leaves r positioned right after the last verified
+// part on success.
+//
+// This matters because the cache key is the mtime-independent content
+// checksum (see contentChecksum), while the archive bytes themselves are
+// not: zip.FileInfoHeader embeds each entry's ModTime, so a file whose mtime
+// changes without its content changing (touch, git checkout, an editor
+// re-save) keeps the same cache key but produces a different byte stream.
+// Trusting a byte-count-based replay in that case would splice old and new
+// framing into one object and report success on a corrupted archive.
+func verifyReplayedParts(r io.ReadSeeker, parts []*partRecord) (bool, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	var buf []byte
+	for _, part := range parts {
+		if int64(cap(buf)) < part.Size {
+			buf = make([]byte, part.Size)
+		}
+		chunk := buf[:part.Size]
+
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return false, fmt.Errorf("could not replay part %d while resuming: %v", part.PartNumber, err)
+		}
+
+		sum := sha256.Sum256(chunk)
+		if hex.EncodeToString(sum[:]) != part.SHA256 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// uploadResumable streams r to bucket/fullKey a part at a time using S3's
+// low-level multipart APIs directly, rather than s3manager, because
+// s3manager's high-level Upload doesn't hand back the upload ID or per-part
+// ETags needed to persist progress. After every completed part it writes
+// cacheKey's state to ~/.cache/glearn/uploads, so a second invocation with
+// the same cacheKey can pick up an existing upload ID and already-completed
+// parts instead of starting over.
+//
+// Resuming only reuses those parts once verifyReplayedParts confirms r
+// reproduces their exact bytes. If r isn't seekable, or the replayed bytes
+// don't match, the stale upload is abandoned and a fresh one is started
+// instead of risking a corrupted archive.
+func uploadResumable(ctx context.Context, client *s3.S3, partSize int64, bucket, fullKey, cacheKey string, r io.Reader) error {
+	if partSize <= 0 {
+		partSize = 5 * 1024 * 1024
+	}
+
+	state, err := loadResumeState(cacheKey)
+	if err != nil {
+		return err
+	}
+
+	if state != nil && (state.Bucket != bucket || state.Key != fullKey) {
+		state = nil
+	}
+
+	if state != nil && len(state.Parts) > 0 {
+		seeker, canSeek := r.(io.ReadSeeker)
+
+		var verified bool
+		if canSeek {
+			verified, err = verifyReplayedParts(seeker, state.Parts)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !verified {
+			abandonUpload(ctx, client, state.Bucket, state.Key, state.UploadID)
+			clearResumeState(cacheKey)
+			state = nil
+
+			if canSeek {
+				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if state == nil {
+		out, err := client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(fullKey),
+		})
+		if err != nil {
+			return fmt.Errorf("Error starting resumable upload to s3: %v", err)
+		}
+
+		state = &resumeState{Bucket: bucket, Key: fullKey, UploadID: *out.UploadId}
+		if err := saveResumeState(cacheKey, state); err != nil {
+			return err
+		}
+	}
+
+	partNumber := int64(len(state.Parts)) + 1
+	buf := make([]byte, partSize)
+
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			md5Sum := md5.Sum(buf[:n])
+			sha256Sum := sha256.Sum256(buf[:n])
+
+			out, err := client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+				Bucket:     aws.String(bucket),
+				Key:        aws.String(fullKey),
+				UploadId:   aws.String(state.UploadID),
+				PartNumber: aws.Int64(partNumber),
+				Body:       bytes.NewReader(buf[:n]),
+				ContentMD5: aws.String(base64.StdEncoding.EncodeToString(md5Sum[:])),
+			})
+			if err != nil {
+				return fmt.Errorf("Error uploading part %d to s3: %v", partNumber, err)
+			}
+
+			state.Parts = append(state.Parts, &partRecord{
+				PartNumber: partNumber,
+				Size:       int64(n),
+				SHA256:     hex.EncodeToString(sha256Sum[:]),
+				ETag:       out.ETag,
+			})
+			if err := saveResumeState(cacheKey, state); err != nil {
+				return err
+			}
+			partNumber++
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	completedParts := make([]*s3.CompletedPart, len(state.Parts))
+	for i, part := range state.Parts {
+		completedParts[i] = &s3.CompletedPart{ETag: part.ETag, PartNumber: aws.Int64(part.PartNumber)}
+	}
+
+	_, err = client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(fullKey),
+		UploadId: aws.String(state.UploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: completedParts,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error completing resumable upload to s3: %v", err)
+	}
+
+	clearResumeState(cacheKey)
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package filebackend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/gSchool/glearn-cli/api/learn"
+)
+
+// s3Backend implements Backend for AWS S3 as well as S3-compatible
+// endpoints such as MinIO, Wasabi, or DigitalOcean Spaces.
+type s3Backend struct {
+	bucket    string
+	keyPrefix string
+	uploader  *s3manager.Uploader
+	client    *s3.S3
+
+	// partSize and resume back uploadResumable, which Upload uses instead of
+	// the uploader when cfg.Resume is set.
+	partSize int64
+	resume   bool
+}
+
+// newS3Backend builds an s3Backend. When useLearnCreds is true (the
+// default "aws" backend) and the config doesn't set a bucket, it asks
+// Learn for temporary S3 credentials via
+// learn.API.RetrieveS3Credentials(); otherwise it uses the static
+// credentials, bucket, and optional custom endpoint from cfg.
+func newS3Backend(cfg Config, useLearnCreds bool) (*s3Backend, error) {
+	region := cfg.Region
+	if region == "" {
+		region = "us-west-2"
+	}
+
+	bucket := cfg.Bucket
+	keyPrefix := cfg.KeyPrefix
+	var creds *credentials.Credentials
+
+	if useLearnCreds && cfg.Bucket == "" {
+		learnCreds, err := learn.API.RetrieveS3Credentials()
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Could not retrieve credentials from Learn. Please ensure you have the right API key in your ~/.glearn-config.yaml: %v",
+				err,
+			)
+		}
+
+		creds = credentials.NewStaticCredentials(learnCreds.AccessKeyID, learnCreds.SecretAccessKey, "")
+		bucket = learnCreds.BucketName
+		if keyPrefix == "" {
+			keyPrefix = learnCreds.KeyPrefix
+		}
+	} else {
+		if bucket == "" {
+			return nil, fmt.Errorf("storage.bucket is required when storage.backend is %q", cfg.Backend)
+		}
+		creds = credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	}
+
+	awsCfg := &aws.Config{
+		Region:           aws.String(region),
+		Credentials:      creds,
+		S3ForcePathStyle: aws.Bool(cfg.PathStyle),
+	}
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(cfg.Endpoint)
+	}
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	uploader := s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		if cfg.PartSize > 0 {
+			u.PartSize = cfg.PartSize
+		}
+		if cfg.Concurrency > 0 {
+			u.Concurrency = cfg.Concurrency
+		}
+		// Keep already-uploaded parts around on failure when cfg.Resume is
+		// set. Upload then goes through uploadResumable instead of this
+		// uploader, and uploadResumable tracks its own parts.
+		u.LeavePartsOnError = cfg.Resume
+	})
+
+	return &s3Backend{
+		bucket:    bucket,
+		keyPrefix: keyPrefix,
+		uploader:  uploader,
+		client:    s3.New(sess),
+		partSize:  cfg.PartSize,
+		resume:    cfg.Resume,
+	}, nil
+}
+
+// Key builds the full key for name, applying the backend's prefix (either
+// configured directly or returned alongside Learn's S3 credentials).
+func (b *s3Backend) Key(name string) string {
+	if b.keyPrefix == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s/%s", b.keyPrefix, name)
+}
+
+func (b *s3Backend) Upload(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	fullKey := b.Key(key)
+
+	if b.resume {
+		if err := uploadResumable(ctx, b.client, b.partSize, b.bucket, fullKey, key, r); err != nil {
+			return "", err
+		}
+		return fullKey, nil
+	}
+
+	_, err := b.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(fullKey),
+		Body:   r,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error uploading assets to s3: %v", err)
+	}
+
+	return fullKey, nil
+}
+
+func (b *s3Backend) TestConnection() error {
+	_, err := b.client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(b.bucket)})
+	if err != nil {
+		return fmt.Errorf("could not reach bucket %q: %v", b.bucket, err)
+	}
+
+	return nil
+}
+
+func (b *s3Backend) SignedURL(key string, ttl time.Duration) (string, error) {
+	req, _ := b.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.Key(key)),
+	})
+
+	return req.Presign(ttl)
+}
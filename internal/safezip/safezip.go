@@ -0,0 +1,154 @@
+// Package safezip provides shared helpers for writing and reading zip
+// archives without falling prey to "zip slip" path-traversal entries
+// (../../etc/passwd, absolute paths, drive letters) or zip-bomb style
+// resource exhaustion. Anything in glearn-cli that writes or extracts a
+// zip archive should go through here rather than trusting archive/zip
+// headers directly.
+package safezip
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"archive/zip"
+)
+
+// DefaultMaxEntries is the default cap on the number of files an archive
+// may contain before extraction refuses to continue.
+const DefaultMaxEntries = 100000
+
+// DefaultMaxUncompressedSize is the default cap, in bytes, on the total
+// uncompressed size of an archive before extraction refuses to continue.
+const DefaultMaxUncompressedSize int64 = 2 << 30 // 2 GiB
+
+// SanitizeName canonicalizes a zip entry name for writing: it forces
+// forward slashes and rejects anything that could be used to escape the
+// archive root (".." segments, a leading slash, a Windows drive letter,
+// or a NUL byte).
+func SanitizeName(name string) (string, error) {
+	if strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("zip entry name %q contains a NUL byte", name)
+	}
+
+	clean := filepath.ToSlash(name)
+
+	if len(clean) >= 2 && clean[1] == ':' {
+		return "", fmt.Errorf("zip entry name %q looks like a Windows drive path", name)
+	}
+
+	if path.IsAbs(clean) {
+		return "", fmt.Errorf("zip entry name %q is an absolute path", name)
+	}
+
+	trailingSlash := strings.HasSuffix(clean, "/")
+	cleaned := path.Clean(clean)
+	if cleaned == "." {
+		return "", fmt.Errorf("zip entry name %q resolves to the archive root", name)
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("zip entry name %q escapes the archive root", name)
+	}
+
+	if trailingSlash {
+		cleaned += "/"
+	}
+
+	return cleaned, nil
+}
+
+// Extract unpacks r into destDir, validating every entry name with
+// SanitizeName plus a prefix check against the resolved destination
+// directory, and enforcing maxEntries/maxUncompressedSize to guard against
+// zip bombs. A maxEntries or maxUncompressedSize of 0 uses the package
+// defaults.
+func Extract(r *zip.Reader, destDir string, maxEntries int, maxUncompressedSize int64) error {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	if maxUncompressedSize <= 0 {
+		maxUncompressedSize = DefaultMaxUncompressedSize
+	}
+
+	if len(r.File) > maxEntries {
+		return fmt.Errorf("archive contains %d entries, which exceeds the limit of %d", len(r.File), maxEntries)
+	}
+
+	destDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+
+	var totalSize int64
+	for _, f := range r.File {
+		totalSize += int64(f.UncompressedSize64)
+		if totalSize > maxUncompressedSize {
+			return fmt.Errorf("archive's uncompressed size exceeds the limit of %d bytes", maxUncompressedSize)
+		}
+	}
+
+	for _, f := range r.File {
+		if err := extractFile(f, destDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractFile writes a single zip.File into destDir after validating its
+// name stays inside destDir once joined and cleaned.
+func extractFile(f *zip.File, destDir string) error {
+	name, err := SanitizeName(f.Name)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(destDir, filepath.FromSlash(name))
+	if target != destDir && !strings.HasPrefix(target, destDir+string(os.PathSeparator)) {
+		return fmt.Errorf("zip entry %q escapes destination directory %q", f.Name, destDir)
+	}
+
+	if f.FileInfo().IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, rc)
+	return err
+}
+
+// ResolvesUnder reports whether path, once symlinks are resolved, is
+// equal to or nested under root (which must already be symlink-resolved).
+// Callers use this to refuse to follow a symlinked source file/directory
+// that escapes the directory being archived.
+func ResolvesUnder(root, path string) (bool, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+
+	if resolved == root {
+		return true, nil
+	}
+
+	return strings.HasPrefix(resolved, root+string(os.PathSeparator)), nil
+}
@@ -0,0 +1,167 @@
+package safezip
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"relative path", "units/lesson-1.md", false},
+		{"relative path with trailing slash", "units/", false},
+		{"parent traversal", "../../etc/passwd", true},
+		{"nested parent traversal", "units/../../etc/passwd", true},
+		{"leading slash", "/etc/passwd", true},
+		{"windows drive letter", `C:\Windows\System32\config`, true},
+		{"NUL byte", "units/lesson\x001.md", true},
+		{"dot only", ".", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := SanitizeName(c.in)
+			if c.wantErr && err == nil {
+				t.Fatalf("SanitizeName(%q): expected an error, got none", c.in)
+			}
+			if !c.wantErr && err != nil {
+				t.Fatalf("SanitizeName(%q): unexpected error: %v", c.in, err)
+			}
+		})
+	}
+}
+
+// craftedZip builds an in-memory zip archive containing a single entry with
+// the given name, bypassing zip.Writer's own name handling so the crafted
+// entry reaches Extract exactly as written.
+func craftedZip(t *testing.T, name, contents string) *zip.Reader {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+
+	f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+	if err != nil {
+		t.Fatalf("CreateHeader: %v", err)
+	}
+	if _, err := f.Write([]byte(contents)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	return r
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	destDir, err := ioutil.TempDir("", "safezip-extract")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(destDir)
+
+	r := craftedZip(t, "../../etc/passwd", "root:x:0:0::/root:/bin/sh")
+
+	if err := Extract(r, destDir, 0, 0); err == nil {
+		t.Fatal("Extract: expected an error for a ../../etc/passwd entry, got none")
+	}
+}
+
+func TestExtractRejectsAbsolutePath(t *testing.T) {
+	destDir, err := ioutil.TempDir("", "safezip-extract")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(destDir)
+
+	r := craftedZip(t, "/etc/passwd", "root:x:0:0::/root:/bin/sh")
+
+	if err := Extract(r, destDir, 0, 0); err == nil {
+		t.Fatal("Extract: expected an error for an absolute-path entry, got none")
+	}
+}
+
+// TestResolvesUnderRejectsSymlinkEscape covers the symlink-escape case
+// compressDirectory guards against: a source entry that's a symlink
+// resolving outside the directory being archived. ResolvesUnder is what
+// walkSource calls to refuse to follow such a symlink.
+func TestResolvesUnderRejectsSymlinkEscape(t *testing.T) {
+	root, err := ioutil.TempDir("", "safezip-root")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	outside, err := ioutil.TempDir("", "safezip-outside")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(outside)
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatalf("EvalSymlinks(root): %v", err)
+	}
+
+	escapingLink := filepath.Join(root, "escape")
+	if err := os.Symlink(outside, escapingLink); err != nil {
+		t.Skipf("Symlink not supported on this platform: %v", err)
+	}
+
+	ok, err := ResolvesUnder(resolvedRoot, escapingLink)
+	if err != nil {
+		t.Fatalf("ResolvesUnder: %v", err)
+	}
+	if ok {
+		t.Fatal("ResolvesUnder: a symlink resolving outside root was reported as resolving under it")
+	}
+
+	nestedFile := filepath.Join(root, "kept.txt")
+	if err := ioutil.WriteFile(nestedFile, []byte("hi"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	innerLink := filepath.Join(root, "inner-link")
+	if err := os.Symlink(nestedFile, innerLink); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	ok, err = ResolvesUnder(resolvedRoot, innerLink)
+	if err != nil {
+		t.Fatalf("ResolvesUnder: %v", err)
+	}
+	if !ok {
+		t.Fatal("ResolvesUnder: a symlink resolving inside root was reported as escaping it")
+	}
+}
+
+func TestExtractEnforcesMaxUncompressedSize(t *testing.T) {
+	destDir, err := ioutil.TempDir("", "safezip-extract")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(destDir)
+
+	r := craftedZip(t, "one.txt", "hello")
+
+	if err := Extract(r, destDir, 0, 0); err != nil {
+		t.Fatalf("Extract: unexpected error for a well-formed entry: %v", err)
+	}
+
+	if err := Extract(r, destDir, 0, 1); err == nil {
+		t.Fatal("Extract: expected an error when uncompressed size exceeds the limit")
+	}
+}